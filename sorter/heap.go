@@ -0,0 +1,35 @@
+package sorter
+
+// heapItem is one candidate line pulled from a sub-file reader, tracked by
+// the index of the reader it came from so the merge loop can pull the next
+// line from the same source once the item is popped.
+type heapItem struct {
+	line      string
+	readerIdx int
+}
+
+// minLineHeap is a container/heap.Interface over heapItem, ordered by the
+// Sorter's comparator, used to find the next line to emit during the k-way
+// merge without rescanning every open sub-file on each step.
+type minLineHeap struct {
+	items []heapItem
+	less  func(a, b string) bool
+}
+
+func (h *minLineHeap) Len() int { return len(h.items) }
+
+func (h *minLineHeap) Less(i, j int) bool { return h.less(h.items[i].line, h.items[j].line) }
+
+func (h *minLineHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *minLineHeap) Push(x any) {
+	h.items = append(h.items, x.(heapItem))
+}
+
+func (h *minLineHeap) Pop() any {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	h.items = old[:n-1]
+	return item
+}
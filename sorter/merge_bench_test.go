@@ -0,0 +1,131 @@
+package sorter
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildSortedReaders splits a run of totalLines globally-sorted, distinct
+// lines into chunkSize-sized runs, emulating the sorted sub-files the split
+// stage hands to the merge stage - one *bufio.Reader per run.
+func buildSortedReaders(totalLines, chunkSize int) []*bufio.Reader {
+	readers := make([]*bufio.Reader, 0, totalLines/chunkSize+1)
+
+	for start := 0; start < totalLines; start += chunkSize {
+		end := start + chunkSize
+		if end > totalLines {
+			end = totalLines
+		}
+
+		var b strings.Builder
+		for i := start; i < end; i++ {
+			fmt.Fprintf(&b, "%08d\n", i)
+		}
+
+		readers = append(readers, bufio.NewReader(strings.NewReader(b.String())))
+	}
+
+	return readers
+}
+
+// mergeLinearScan is the package's original merge strategy, kept here only
+// for comparison: on every step it rescans every reader's current line to
+// find the minimum, an O(total lines * number of readers) approach that
+// mergeAndCount's heap-based merge replaced.
+func mergeLinearScan(readers []*bufio.Reader) (int, error) {
+	lines := make([]string, len(readers))
+	live := len(readers)
+
+	for i, r := range readers {
+		line, _, err := r.ReadLine()
+		if err != nil {
+			return 0, err
+		}
+
+		lines[i] = string(line)
+	}
+
+	emitted := 0
+	for live > 0 {
+		minIndex := -1
+
+		for i := range lines {
+			if lines[i] == "" {
+				continue
+			}
+
+			if minIndex == -1 || lines[i] < lines[minIndex] {
+				minIndex = i
+			}
+		}
+
+		emitted++
+
+		line, _, err := readers[minIndex].ReadLine()
+		if err != nil {
+			lines[minIndex] = ""
+			live--
+			continue
+		}
+
+		lines[minIndex] = string(line)
+	}
+
+	return emitted, nil
+}
+
+// mergeHeap is the k-way merge mergeAndCount uses today, isolated from its
+// bucket-file bookkeeping so it can be benchmarked on equal footing with
+// mergeLinearScan.
+func mergeHeap(readers []*bufio.Reader) (int, error) {
+	h := &minLineHeap{items: make([]heapItem, 0, len(readers)), less: func(a, b string) bool { return a < b }}
+
+	for i, r := range readers {
+		line, _, err := r.ReadLine()
+		if err != nil {
+			return 0, err
+		}
+
+		h.items = append(h.items, heapItem{line: string(line), readerIdx: i})
+	}
+	heap.Init(h)
+
+	emitted := 0
+	for h.Len() > 0 {
+		item := heap.Pop(h).(heapItem)
+		emitted++
+
+		line, _, err := readers[item.readerIdx].ReadLine()
+		if err != nil {
+			continue
+		}
+
+		heap.Push(h, heapItem{line: string(line), readerIdx: item.readerIdx})
+	}
+
+	return emitted, nil
+}
+
+const benchTotalLines = 20000
+
+func benchmarkMerge(b *testing.B, chunkSize int, merge func([]*bufio.Reader) (int, error)) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		readers := buildSortedReaders(benchTotalLines, chunkSize)
+		b.StartTimer()
+
+		if _, err := merge(readers); err != nil {
+			b.Fatalf("merge: %v", err)
+		}
+	}
+}
+
+func BenchmarkMergeLinearScan_ChunkSize10(b *testing.B)   { benchmarkMerge(b, 10, mergeLinearScan) }
+func BenchmarkMergeHeap_ChunkSize10(b *testing.B)         { benchmarkMerge(b, 10, mergeHeap) }
+func BenchmarkMergeLinearScan_ChunkSize100(b *testing.B)  { benchmarkMerge(b, 100, mergeLinearScan) }
+func BenchmarkMergeHeap_ChunkSize100(b *testing.B)        { benchmarkMerge(b, 100, mergeHeap) }
+func BenchmarkMergeLinearScan_ChunkSize1000(b *testing.B) { benchmarkMerge(b, 1000, mergeLinearScan) }
+func BenchmarkMergeHeap_ChunkSize1000(b *testing.B)       { benchmarkMerge(b, 1000, mergeHeap) }
@@ -0,0 +1,60 @@
+package sorter
+
+// Option configures a Sorter at construction time.
+type Option func(*Sorter)
+
+// WithParallelism sets the number of worker goroutines used to sort chunks
+// concurrently during the split stage. The default is runtime.NumCPU().
+// Passing n <= 1 disables concurrent sorting: a single worker goroutine sorts
+// chunks one at a time.
+func WithParallelism(n int) Option {
+	return func(s *Sorter) {
+		s.parallelism = n
+	}
+}
+
+// WithLess overrides the comparator used to order lines (or, if WithKeyFunc
+// is also set, the extracted keys). The default is lexicographic (a < b),
+// which reproduces the package's original default-ordering behavior.
+func WithLess(less func(a, b string) bool) Option {
+	return func(s *Sorter) {
+		s.less = less
+	}
+}
+
+// WithKeyFunc extracts a sort key from each line so callers can sort by, say,
+// a CSV column or a numeric prefix while still emitting the full original
+// line to the output. When set, WithLess compares extracted keys instead of
+// whole lines.
+func WithKeyFunc(keyFunc func(line string) string) Option {
+	return func(s *Sorter) {
+		s.keyFunc = keyFunc
+	}
+}
+
+// WithTopK limits the final descending walk to the k most-duplicated lines,
+// short-circuiting the lower-count buckets entirely once k lines have been
+// emitted. k <= 0 disables the limit (the default).
+func WithTopK(k int) Option {
+	return func(s *Sorter) {
+		s.topK = k
+	}
+}
+
+// WithSegmentSize sets the maximum size in bytes of a single segment file
+// written by CreateSegmentedOutput before it rolls over to the next one.
+// Default is 512 MiB.
+func WithSegmentSize(bytes int64) Option {
+	return func(s *Sorter) {
+		s.segmentSize = bytes
+	}
+}
+
+// WithTotalSizeLimit caps the total bytes CreateSegmentedOutput may write
+// across all segments. Exceeding it returns *ErrTotalSizeLimit. bytes <= 0
+// disables the cap (the default).
+func WithTotalSizeLimit(bytes int64) Option {
+	return func(s *Sorter) {
+		s.totalSizeLimit = bytes
+	}
+}
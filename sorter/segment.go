@@ -0,0 +1,322 @@
+package sorter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// defaultSegmentSize is the segment size used when WithSegmentSize is not
+// supplied.
+const defaultSegmentSize = 512 * 1024 * 1024
+
+// manifestFileName is where CreateSegmentedOutput writes its manifest, and
+// where Update looks for it next to a previous run's output.
+const manifestFileName = "output.index"
+
+// segmentManifestEntry describes one segment file written for a given
+// duplicate-count bucket. byteOffset is the cumulative number of bytes
+// already written for that bucket in earlier segments, so a reader can
+// locate a bucket's data across its segments without opening the earlier
+// ones.
+type segmentManifestEntry struct {
+	Count       int    `json:"count"`
+	SegmentPath string `json:"segmentPath"`
+	ByteOffset  int64  `json:"byteOffset"`
+	LineCount   int    `json:"lineCount"`
+}
+
+// ErrTotalSizeLimit is returned when writing a segmented output would exceed
+// the cap configured via WithTotalSizeLimit.
+type ErrTotalSizeLimit struct {
+	Limit int64
+}
+
+func (e *ErrTotalSizeLimit) Error() string {
+	return fmt.Sprintf("sorter: total output size would exceed limit of %d bytes", e.Limit)
+}
+
+// reserveBytes accounts n additional bytes against the total output size
+// budget, returning *ErrTotalSizeLimit if the configured cap would be
+// exceeded. It is a no-op when no cap (WithTotalSizeLimit) is configured.
+func (s *Sorter) reserveBytes(n int64) error {
+	if s.totalSizeLimit <= 0 {
+		return nil
+	}
+
+	s.totalBytesMu.Lock()
+	defer s.totalBytesMu.Unlock()
+
+	if s.totalBytesWritten+n > s.totalSizeLimit {
+		return &ErrTotalSizeLimit{Limit: s.totalSizeLimit}
+	}
+
+	s.totalBytesWritten += n
+
+	return nil
+}
+
+// segmentWriter writes the lines of a single duplicate-count bucket across
+// one or more size-capped segment files (e.g. bucket-000012-seg-0003.dat),
+// rolling to a new segment whenever the current one would exceed
+// s.segmentSize. Each segment is pre-allocated with Truncate and trimmed
+// back to its actual size on rollover/close.
+type segmentWriter struct {
+	s     *Sorter
+	count int
+
+	segIdx int
+	file   *os.File
+	writer *bufio.Writer
+
+	written int64 // bytes written into the current segment
+	lines   int   // lines written into the current segment
+	offset  int64 // cumulative bytes across prior segments for this bucket
+
+	manifest []segmentManifestEntry
+}
+
+func newSegmentWriter(s *Sorter, count int) *segmentWriter {
+	return &segmentWriter{s: s, count: count}
+}
+
+func (w *segmentWriter) writeLine(line string) error {
+	const op = "segmentWriter.writeLine"
+
+	need := int64(len(line) + len(byteRowDelimiter))
+
+	// Reserve against the total size budget before opening/growing a
+	// segment, so hitting WithTotalSizeLimit never leaves behind a
+	// segment file pre-allocated to segmentSize that we never wrote into.
+	if err := w.s.reserveBytes(need); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if w.file == nil || w.written+need > w.s.segmentSize {
+		if err := w.rollover(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if _, err := w.writer.WriteString(line); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := w.writer.Write(byteRowDelimiter); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.written += need
+	w.lines++
+
+	return nil
+}
+
+// rollover closes the current segment (if any) and opens the next one,
+// pre-allocated to s.segmentSize via Truncate.
+func (w *segmentWriter) rollover() error {
+	const op = "segmentWriter.rollover"
+
+	if err := w.closeCurrent(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	path := fmt.Sprintf("bucket-%06d-seg-%04d.dat", w.count, w.segIdx)
+	w.segIdx++
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := f.Truncate(w.s.segmentSize); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.file = f
+	w.writer = bufio.NewWriter(f)
+	w.written = 0
+	w.lines = 0
+
+	return nil
+}
+
+// closeCurrent flushes and truncates the current segment back to its actual
+// byte count, records its manifest entry, and advances the cumulative
+// offset for the bucket.
+func (w *segmentWriter) closeCurrent() error {
+	const op = "segmentWriter.closeCurrent"
+
+	if w.file == nil {
+		return nil
+	}
+
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := w.file.Truncate(w.written); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	name := w.file.Name()
+
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.manifest = append(w.manifest, segmentManifestEntry{
+		Count:       w.count,
+		SegmentPath: name,
+		ByteOffset:  w.offset,
+		LineCount:   w.lines,
+	})
+
+	w.offset += w.written
+	w.file = nil
+	w.writer = nil
+
+	return nil
+}
+
+// Close flushes and finalizes whichever segment is currently open.
+func (w *segmentWriter) Close() error {
+	return w.closeCurrent()
+}
+
+// writeSegmentedBuckets writes every bucket file from the highest
+// duplicate-count to the lowest, through a segmentWriter per bucket, and
+// returns the combined manifest across all buckets and segments.
+func (s *Sorter) writeSegmentedBuckets() ([]segmentManifestEntry, error) {
+	const op = "Sorter.writeSegmentedBuckets"
+
+	keys := make([]int, 0, len(s.subFilesWithCount))
+	for key, file := range s.subFilesWithCount {
+		keys = append(keys, key)
+
+		if _, err := file.Seek(0, 0); err != nil {
+			return nil, fmt.Errorf("%s, %w", op, err)
+		}
+	}
+
+	// sort keys due to golang hash map is unsorted
+	sort.Ints(keys)
+
+	readersWithCount := s.getSubFileWithCountReaders()
+
+	var manifest []segmentManifestEntry
+
+	emitted := 0
+	for i := len(keys) - 1; i >= 0; i-- {
+		if s.topK > 0 && emitted >= s.topK {
+			break
+		}
+
+		count := keys[i]
+		w := newSegmentWriter(s, count)
+
+		writeErr := func() error {
+			for {
+				if s.topK > 0 && emitted >= s.topK {
+					return nil
+				}
+
+				line, _, err := readersWithCount[count].ReadLine()
+				if err != nil {
+					if errors.Is(err, io.EOF) {
+						return nil
+					}
+					return err
+				}
+
+				if err := w.writeLine(string(line)); err != nil {
+					return err
+				}
+
+				emitted++
+			}
+		}()
+
+		// Always close the in-flight segment - even on error - so it gets
+		// flushed and truncated back to its actual size instead of being
+		// left on disk pre-allocated to the full segmentSize.
+		if closeErr := w.Close(); closeErr != nil && writeErr == nil {
+			writeErr = closeErr
+		}
+
+		if writeErr != nil {
+			return nil, fmt.Errorf("%s, %w", op, writeErr)
+		}
+
+		manifest = append(manifest, w.manifest...)
+	}
+
+	return manifest, nil
+}
+
+// writeManifestFile writes one JSON object per line to path, one per
+// segmentManifestEntry, so a reader can stream it without loading the whole
+// thing into memory.
+func writeManifestFile(path string, manifest []segmentManifestEntry) error {
+	const op = "writeManifestFile"
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, entry := range manifest {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// CreateSegmentedOutput sorts "input.txt" the same way CreateSortedCountFile
+// does, but instead of a single monolithic output.txt it writes each
+// duplicate-count bucket across one or more WithSegmentSize-capped segment
+// files and emits a manifest at "output.index" listing where each bucket's
+// data lives, so a reader can seek directly to, say, all lines with
+// duplicate count >= 100 without scanning the whole output. After calling,
+// the caller should still defer Close.
+func (s *Sorter) CreateSegmentedOutput() ([]segmentManifestEntry, error) {
+	const op = "Sorter.CreateSegmentedOutput"
+
+	inputFile, err := os.Open("input.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer inputFile.Close()
+
+	readers, err := s.splitFileToChunks(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	log.Printf("INFO: number of subfiles: %d", len(readers))
+
+	if err := s.mergeAndCount(readers); err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	manifest, err := s.writeSegmentedBuckets()
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	if err := writeManifestFile(manifestFileName, manifest); err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return manifest, nil
+}
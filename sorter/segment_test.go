@@ -0,0 +1,82 @@
+package sorter
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// chdirTemp switches the working directory to a fresh temp dir for the
+// duration of the test, restoring the original on cleanup. CreateSortedCountFile
+// and friends all read/write fixed relative paths ("input.txt", "output.txt",
+// "output.index"), so exercising them means running from a throwaway cwd.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := os.Chdir(cwd); err != nil {
+			t.Fatalf("Chdir back: %v", err)
+		}
+	})
+
+	return dir
+}
+
+func TestCreateSegmentedOutputThenUpdateRoundTrip(t *testing.T) {
+	chdirTemp(t)
+
+	baseline := "apple\napple\napple\nbanana\nbanana\ncherry\n"
+	if err := os.WriteFile("input.txt", []byte(baseline), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s1, err := New(2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s1.Close()
+
+	if _, err := s1.CreateSegmentedOutput(); err != nil {
+		t.Fatalf("CreateSegmentedOutput: %v", err)
+	}
+
+	// Delta: "+apple" moves an existing line from count 3 into a count-4
+	// bucket the baseline did not have; "-banana" twice removes every
+	// occurrence of a line, dropping it from the output entirely; "+durian"
+	// adds a line absent from the baseline straight into the count-1 bucket.
+	delta := "+apple\n-banana\n-banana\n+durian\n"
+
+	s2, err := New(2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s2.Close()
+
+	if _, err := s2.Update("output.txt", strings.NewReader(delta)); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got, err := os.ReadFile("output.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// apple's existing count is incremented rather than shadowed by a
+	// second, unmerged "apple\t1" entry; banana disappears entirely;
+	// durian joins cherry in the count-1 bucket.
+	want := "apple\t4\ncherry\t1\ndurian\t1\n"
+	if string(got) != want {
+		t.Errorf("output.txt = %q, want %q", got, want)
+	}
+}
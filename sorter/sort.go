@@ -2,13 +2,17 @@ package sorter
 
 import (
 	"bufio"
+	"container/heap"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"sync"
 )
 
 const (
@@ -26,6 +30,29 @@ type Sorter struct {
 	// limit in rows
 	chunkSize int
 
+	// number of workers sorting chunks concurrently during the split stage
+	parallelism int
+
+	// comparator used to order lines (or extracted keys, see keyFunc)
+	less func(a, b string) bool
+
+	// optional key extractor; when set, less compares keyFunc(line) instead
+	// of the raw line
+	keyFunc func(line string) string
+
+	// when > 0, limit the final output to the topK most-duplicated lines
+	topK int
+
+	// max size in bytes of a single segment file in segmented output mode
+	segmentSize int64
+
+	// when > 0, cap on total bytes written across all segments; exceeding
+	// it returns *ErrTotalSizeLimit
+	totalSizeLimit int64
+
+	totalBytesMu      sync.Mutex
+	totalBytesWritten int64
+
 	// all subs files for external sort merge
 	subFiles []*os.File
 
@@ -33,18 +60,37 @@ type Sorter struct {
 }
 
 // New - return new sorter struct
-func New(chunkSize int) (*Sorter, error) {
+func New(chunkSize int, opts ...Option) (*Sorter, error) {
 	tempDir, err := os.MkdirTemp("", "*-external_merge")
 	if err != nil {
 		return nil, err
 	}
 
-	return &Sorter{
+	s := &Sorter{
 		tmpDir:            tempDir,
 		chunkSize:         chunkSize,
+		parallelism:       runtime.NumCPU(),
+		less:              func(a, b string) bool { return a < b },
+		segmentSize:       defaultSegmentSize,
 		subFiles:          make([]*os.File, 0, 100),
 		subFilesWithCount: make(map[int]*os.File, 100),
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// lineLess reports whether line a sorts before line b, comparing extracted
+// keys instead of the raw lines when keyFunc is set.
+func (s *Sorter) lineLess(a, b string) bool {
+	if s.keyFunc != nil {
+		return s.less(s.keyFunc(a), s.keyFunc(b))
+	}
+
+	return s.less(a, b)
 }
 
 // Close - close all open files, then clean up garbage
@@ -71,47 +117,117 @@ func (s *Sorter) Close() error {
 	return nil
 }
 
-func (s *Sorter) splitFileToChunks(file string) ([]*bufio.Reader, error) {
+// chunkJob is one unvalidated batch of lines read off the input file, handed
+// to a sort worker along with the chunk index so the resulting sub-file can
+// be slotted back into the original order.
+type chunkJob struct {
+	idx   int
+	lines []string
+}
+
+// splitFileToChunks reads r on a reader goroutine and fans the chunks out
+// to a pool of s.parallelism workers, each of which sorts its chunk and
+// writes it to a sub-file via newSortedSubFile. The first worker (or reader)
+// error cancels the context the reader is watching, so the whole pipeline
+// shuts down instead of reading the rest of an input nobody wants anymore.
+func (s *Sorter) splitFileToChunks(r io.Reader) ([]*bufio.Reader, error) {
 	const op = "Sorter.splitFileToChunks"
 
-	// Open the input file
-	inputFile, err := os.Open(file)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	// Create a buffered reader for the input
+	input := bufio.NewReader(r)
+
+	workers := s.parallelism
+	if workers < 1 {
+		workers = 1
 	}
-	defer inputFile.Close()
 
-	// Create a buffered reader for the input file
-	input := bufio.NewReader(inputFile)
+	jobs := make(chan chunkJob)
 
-	fCnt := 0
-	for ; ; fCnt++ {
-		// Read a chunk of the input file
-		chunk := make([]string, 0, s.chunkSize)
-		for j := 0; j < s.chunkSize; j++ {
-			line, _, err := input.ReadLine()
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					break
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		wg sync.WaitGroup
+
+		mu      sync.Mutex
+		results = make(map[int]*os.File)
+
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				subFile, err := s.newSortedSubFile(job.lines, job.idx)
+				if err != nil {
+					fail(err)
+					continue
 				}
-				return nil, fmt.Errorf("%s: %w", op, err)
+
+				mu.Lock()
+				results[job.idx] = subFile
+				mu.Unlock()
 			}
+		}()
+	}
 
-			chunk = append(chunk, string(line))
-		}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+
+		for fCnt := 0; ; fCnt++ {
+			// Read a chunk of the input file
+			chunk := make([]string, 0, s.chunkSize)
+			for j := 0; j < s.chunkSize; j++ {
+				line, _, err := input.ReadLine()
+				if err != nil {
+					if errors.Is(err, io.EOF) {
+						break
+					}
+
+					fail(fmt.Errorf("%s: %w", op, err))
+					return
+				}
 
-		// If the chunk is empty, we've reached the end of the input file
-		if len(chunk) == 0 {
-			break
-		}
+				chunk = append(chunk, string(line))
+			}
 
-		subFile, err := s.newSortedSubFile(chunk, fCnt)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			// If the chunk is empty, we've reached the end of the input file
+			if len(chunk) == 0 {
+				return
+			}
+
+			select {
+			case jobs <- chunkJob{idx: fCnt, lines: chunk}:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
+
+	wg.Wait()
 
-		// store sub file
-		s.subFiles = append(s.subFiles, subFile)
+	if firstErr != nil {
+		return nil, fmt.Errorf("%s: %w", op, firstErr)
+	}
+
+	// Results land in a map because workers finish out of order; reassemble
+	// s.subFiles in chunk order so the merge stage sees a stable layout.
+	s.subFiles = make([]*os.File, len(results))
+	for idx, subFile := range results {
+		s.subFiles[idx] = subFile
 	}
 
 	return s.getSubFileReaders(), nil
@@ -125,8 +241,8 @@ func (s *Sorter) newSortedSubFile(chunk []string, subNumber int) (f *os.File, er
 		return nil, nil
 	}
 
-	// Sort the chunk
-	sort.Strings(chunk)
+	// Sort the chunk using the configured comparator (default: a < b)
+	sort.Slice(chunk, func(i, j int) bool { return s.lineLess(chunk[i], chunk[j]) })
 
 	f, err = os.Create(
 		filepath.Join(s.tmpDir, fmt.Sprintf("sub-%d.txt", subNumber)),
@@ -205,100 +321,78 @@ func (s *Sorter) writeToFileWithCount(data string, count int) error {
 	return nil
 }
 
-// CreateSortedCountFile - sort algorithm.
-// After call this one should defer Close
-// Steps:
-//  1. split file to chunks
-//  2. read all chunks
-//  3. look for a minimum string value and count it
-//  4. write uniq lines with deduplicate-count into new files which has count in a name
-//  5. read this file with count in a name from biggest to lower and write line by line to output file
-func (s *Sorter) CreateSortedCountFile() (os.FileInfo, error) {
-	const op = "Sorter.CreateSortedCountFile"
-
-	// Read the input file in chunks and create sub-files
-	readers, err := s.splitFileToChunks("input.txt")
-	if err != nil {
-		return nil, fmt.Errorf("%s, %w", op, err)
-	}
-
-	log.Printf("INFO: number of subfiles: %d", len(readers))
+// mergeAndCount runs the k-way merge over readers, writing each distinct
+// line with its duplicate count into the bucket file named for that count.
+func (s *Sorter) mergeAndCount(readers []*bufio.Reader) error {
+	const op = "Sorter.mergeAndCount"
 
 	var (
-		// number of open files
-		fileCount = len(readers)
-
-		// Create a slice to hold the current lines for each sub-file
-		lines = make([]string, 0, len(readers))
-
 		currentLineValue   = ""
 		duplicateLineCount = 1
 	)
 
-	// Read the first line from each sub-file
-	for _, r := range readers {
+	// Seed the heap with one line per sub-file reader
+	h := &minLineHeap{items: make([]heapItem, 0, len(readers)), less: s.lineLess}
+	for i, r := range readers {
 		line, _, err := r.ReadLine()
 		if err != nil {
-			return nil, fmt.Errorf("%s, %w", op, err)
+			return fmt.Errorf("%s, %w", op, err)
 		}
 
-		lines = append(lines, string(line))
+		h.items = append(h.items, heapItem{line: string(line), readerIdx: i})
 	}
+	heap.Init(h)
 
-	for fileCount > 0 {
-		var (
-			minLine  string
-			minIndex int
-		)
-
-		// Find the minimum line
-		for i, line := range lines {
-			if line != "" && (minLine == "" || line < minLine) {
-				minLine = line
-				minIndex = i
-			}
-		}
+	// Repeatedly pop the smallest line, dedup/count it, then pull the next
+	// line from the reader it came from and push it back (unless exhausted).
+	for h.Len() > 0 {
+		item := heap.Pop(h).(heapItem)
 
 		switch currentLineValue {
 		case "":
-			currentLineValue = minLine
-		case minLine:
+			currentLineValue = item.line
+		case item.line:
 			duplicateLineCount++
 		default:
 			if err := s.writeToFileWithCount(currentLineValue, duplicateLineCount); err != nil {
-				return nil, fmt.Errorf("%s, %w", op, err)
+				return fmt.Errorf("%s, %w", op, err)
 			}
 
-			currentLineValue = minLine
+			currentLineValue = item.line
 			duplicateLineCount = 1
 		}
 
-		// Read the next line from the sub-file
-		line, _, err := readers[minIndex].ReadLine()
+		line, _, err := readers[item.readerIdx].ReadLine()
 		if err != nil {
-			// If the sub-file is exhausted, decrease fileCount by one
+			// If the sub-file is exhausted, don't push it back onto the heap
 			if errors.Is(err, io.EOF) {
-				fileCount--
-				lines[minIndex] = ""
 				continue
 			}
-			return nil, fmt.Errorf("%s, %w", op, err)
-		} else {
-			lines[minIndex] = string(line)
+			return fmt.Errorf("%s, %w", op, err)
 		}
+
+		heap.Push(h, heapItem{line: string(line), readerIdx: item.readerIdx})
 	}
 
 	// don't forget last row
 	if err := s.writeToFileWithCount(currentLineValue, duplicateLineCount); err != nil {
-		return nil, fmt.Errorf("%s, %w", op, err)
+		return fmt.Errorf("%s, %w", op, err)
 	}
 
+	return nil
+}
+
+// writeBucketsDescending writes every bucket file to out, from the highest
+// duplicate-count bucket to the lowest.
+func (s *Sorter) writeBucketsDescending(out io.Writer) error {
+	const op = "Sorter.writeBucketsDescending"
+
 	keys := make([]int, 0, len(s.subFilesWithCount))
 	for key, file := range s.subFilesWithCount {
 		keys = append(keys, key)
 
 		if _, err := file.Seek(0, 0); err != nil {
-			return nil, fmt.Errorf("%s, %w", op, err)
+			return fmt.Errorf("%s, %w", op, err)
 		}
 	}
 
@@ -307,33 +401,179 @@ func (s *Sorter) CreateSortedCountFile() (os.FileInfo, error) {
 
 	readersWithCount := s.getSubFileWithCountReaders()
 
-	outputFile, err := os.Create("output.txt")
-	if err != nil {
-		return nil, fmt.Errorf("%s, %w", op, err)
-	}
-	defer outputFile.Close()
+	output := bufio.NewWriter(out)
 
-	// Create a buffered writer for the output file
-	output := bufio.NewWriter(outputFile)
-
-	// write from bigger to lower duplicate-counter
+	// write from bigger to lower duplicate-counter, stopping once topK lines
+	// have been emitted (topK <= 0 means no limit) without reading the
+	// remaining lower-count buckets
+	emitted := 0
 	for i := len(keys) - 1; i >= 0; i-- {
+		if s.topK > 0 && emitted >= s.topK {
+			break
+		}
+
 		for {
 			line, _, err := readersWithCount[keys[i]].ReadLine()
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					break
 				}
-				return nil, fmt.Errorf("%s, %w", op, err)
+				return fmt.Errorf("%s, %w", op, err)
 			}
 
 			if _, err := output.Write(append(line, byteRowDelimiter...)); err != nil {
-				return nil, fmt.Errorf("%s, %w", op, err)
+				return fmt.Errorf("%s, %w", op, err)
 			}
+
+			emitted++
+			if s.topK > 0 && emitted >= s.topK {
+				break
+			}
+		}
+	}
+
+	return output.Flush()
+}
+
+// Sort is the io.Reader/io.Writer entry point: it reads in, sorts and counts
+// duplicate lines (per the configured comparator/key, see WithLess and
+// WithKeyFunc), and writes lines to out ordered from the most-duplicated to
+// the least. opts is applied before the run, so per-call options such as
+// WithLess or WithKeyFunc can be supplied here instead of at New.
+//
+// After calling Sort, the caller should still defer Close to clean up the
+// sorter's temp files.
+func (s *Sorter) Sort(in io.Reader, out io.Writer, opts ...Option) error {
+	const op = "Sorter.Sort"
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	readers, err := s.splitFileToChunks(in)
+	if err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	log.Printf("INFO: number of subfiles: %d", len(readers))
+
+	if err := s.mergeAndCount(readers); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	if err := s.writeBucketsDescending(out); err != nil {
+		return fmt.Errorf("%s, %w", op, err)
+	}
+
+	return nil
+}
+
+// CreateSortedCountFile - sort algorithm, reading from "input.txt" and
+// writing to "output.txt". After call this one should defer Close.
+// Steps:
+//  1. split file to chunks
+//  2. read all chunks
+//  3. look for a minimum string value and count it
+//  4. write uniq lines with deduplicate-count into new files which has count in a name
+//  5. read this file with count in a name from biggest to lower and write line by line to output file
+func (s *Sorter) CreateSortedCountFile() (os.FileInfo, error) {
+	const op = "Sorter.CreateSortedCountFile"
+
+	inputFile, err := os.Open("input.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create("output.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer outputFile.Close()
+
+	if err := s.Sort(inputFile, outputFile); err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	stat, err := outputFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return stat, nil
+}
+
+// CreateTopKFile sorts "input.txt" and writes only the k most-duplicated
+// lines, descending by duplicate count, to "output.txt". It shares the
+// bucket-file merge path with CreateSortedCountFile but short-circuits once
+// k lines have been emitted. After calling, the caller should still defer
+// Close.
+func (s *Sorter) CreateTopKFile(k int) (os.FileInfo, error) {
+	const op = "Sorter.CreateTopKFile"
+
+	inputFile, err := os.Open("input.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create("output.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer outputFile.Close()
+
+	if err := s.Sort(inputFile, outputFile, WithTopK(k)); err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	stat, err := outputFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return stat, nil
+}
+
+// CreateTopKFileInMemory is an alternative to CreateTopKFile for when k is
+// small relative to the number of unique lines: it keeps only the current
+// top-k candidates in memory via a bounded min-heap fed directly by the
+// merge stage, never writing the intermediate tmp-file-with-count-* bucket
+// files at all. After calling, the caller should still defer Close.
+func (s *Sorter) CreateTopKFileInMemory(k int) (os.FileInfo, error) {
+	const op = "Sorter.CreateTopKFileInMemory"
+
+	inputFile, err := os.Open("input.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer inputFile.Close()
+
+	readers, err := s.splitFileToChunks(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	log.Printf("INFO: number of subfiles: %d", len(readers))
+
+	top, err := s.mergeTopKInMemory(readers, k)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	outputFile, err := os.Create("output.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer outputFile.Close()
+
+	output := bufio.NewWriter(outputFile)
+	for _, cl := range top {
+		if _, err := output.WriteString(cl.line + stringRowDelimiter); err != nil {
+			return nil, fmt.Errorf("%s, %w", op, err)
 		}
 	}
 
-	// Flush the output buffer
 	if err := output.Flush(); err != nil {
 		return nil, fmt.Errorf("%s, %w", op, err)
 	}
@@ -0,0 +1,68 @@
+package sorter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortDedupCounts(t *testing.T) {
+	input := "banana\napple\nbanana\ncherry\napple\napple\n"
+
+	s, err := New(2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	var out strings.Builder
+	if err := s.Sort(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Sort: %v", err)
+	}
+
+	// descending by duplicate count: apple(3), banana(2), cherry(1)
+	if got, want := out.String(), "apple\t3\nbanana\t2\ncherry\t1\n"; got != want {
+		t.Errorf("Sort() = %q, want %q", got, want)
+	}
+}
+
+func TestSortTopKShortCircuitsLowerBuckets(t *testing.T) {
+	input := "a\na\na\nb\nb\nc\n"
+
+	s, err := New(2, WithTopK(1))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	var out strings.Builder
+	if err := s.Sort(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Sort: %v", err)
+	}
+
+	if got, want := out.String(), "a\t3\n"; got != want {
+		t.Errorf("Sort() = %q, want %q", got, want)
+	}
+}
+
+func TestSortWithKeyFunc(t *testing.T) {
+	input := "c:3\na:1\nb:2\n"
+
+	keyFunc := func(line string) string { return strings.SplitN(line, ":", 2)[0] }
+
+	s, err := New(2, WithKeyFunc(keyFunc))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	var out strings.Builder
+	if err := s.Sort(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Sort: %v", err)
+	}
+
+	// every line occurs once, so duplicate-count buckets tie and fall back
+	// to comparator order over the extracted key (a, b, c)
+	if got, want := out.String(), "a:1\t1\nb:2\t1\nc:3\t1\n"; got != want {
+		t.Errorf("Sort() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package sorter
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// countedLine pairs a line with how many times it appeared, used by the
+// in-memory top-K path.
+type countedLine struct {
+	line  string
+	count int
+}
+
+// topKHeap is a bounded min-heap of countedLine ordered by count (ties
+// broken by line), so the smallest of the current top-K sits at the root
+// and can be evicted in O(log k) whenever a bigger count arrives.
+type topKHeap []countedLine
+
+func (h topKHeap) Len() int { return len(h) }
+
+func (h topKHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+
+	return h[i].line < h[j].line
+}
+
+func (h topKHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *topKHeap) Push(x any) {
+	*h = append(*h, x.(countedLine))
+}
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// mergeTopKInMemory performs the same k-way merge as mergeAndCount, but
+// instead of writing every distinct line out to a bucket file, it keeps only
+// the current top-k (count, line) pairs in a bounded min-heap. This avoids
+// the tmp-file-with-count-* files entirely and suits workloads where k is
+// small relative to the number of unique lines. The result is returned
+// ordered from the most-duplicated line to the least.
+func (s *Sorter) mergeTopKInMemory(readers []*bufio.Reader, k int) ([]countedLine, error) {
+	const op = "Sorter.mergeTopKInMemory"
+
+	var top topKHeap
+
+	// k <= 0 means unlimited, matching the bucket-file path (WithTopK): keep
+	// every line instead of bounding the heap.
+	consider := func(line string, count int) {
+		if k > 0 && len(top) >= k {
+			if count <= top[0].count {
+				return
+			}
+
+			heap.Pop(&top)
+		}
+
+		heap.Push(&top, countedLine{line: line, count: count})
+	}
+
+	var (
+		currentLineValue   = ""
+		duplicateLineCount = 1
+	)
+
+	h := &minLineHeap{items: make([]heapItem, 0, len(readers)), less: s.lineLess}
+	for i, r := range readers {
+		line, _, err := r.ReadLine()
+		if err != nil {
+			return nil, fmt.Errorf("%s, %w", op, err)
+		}
+
+		h.items = append(h.items, heapItem{line: string(line), readerIdx: i})
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		item := heap.Pop(h).(heapItem)
+
+		switch currentLineValue {
+		case "":
+			currentLineValue = item.line
+		case item.line:
+			duplicateLineCount++
+		default:
+			consider(currentLineValue, duplicateLineCount)
+			currentLineValue = item.line
+			duplicateLineCount = 1
+		}
+
+		line, _, err := readers[item.readerIdx].ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				continue
+			}
+			return nil, fmt.Errorf("%s, %w", op, err)
+		}
+
+		heap.Push(h, heapItem{line: string(line), readerIdx: item.readerIdx})
+	}
+	consider(currentLineValue, duplicateLineCount)
+
+	result := make([]countedLine, len(top))
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(&top).(countedLine)
+	}
+
+	return result, nil
+}
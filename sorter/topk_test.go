@@ -0,0 +1,67 @@
+package sorter
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCreateTopKFileInMemory(t *testing.T) {
+	chdirTemp(t)
+
+	input := "apple\napple\napple\nbanana\nbanana\ncherry\n"
+	if err := os.WriteFile("input.txt", []byte(input), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s, err := New(2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.CreateTopKFileInMemory(2); err != nil {
+		t.Fatalf("CreateTopKFileInMemory: %v", err)
+	}
+
+	got, err := os.ReadFile("output.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// unlike the bucket-file path, CreateTopKFileInMemory writes the bare
+	// line without a "\tcount" suffix (see mergeTopKInMemory's caller).
+	if want := "apple\nbanana\n"; string(got) != want {
+		t.Errorf("output.txt = %q, want %q", got, want)
+	}
+}
+
+func TestCreateTopKFileInMemoryUnlimitedWhenKNotPositive(t *testing.T) {
+	chdirTemp(t)
+
+	input := "apple\napple\nbanana\ncherry\n"
+	if err := os.WriteFile("input.txt", []byte(input), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s, err := New(2)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.CreateTopKFileInMemory(0); err != nil {
+		t.Fatalf("CreateTopKFileInMemory: %v", err)
+	}
+
+	got, err := os.ReadFile("output.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// Descending by count, then - since this path pops the top-k heap's
+	// ascending (count, line) order and writes it out in reverse - descending by line
+	// among ties (banana and cherry both have count 1).
+	if want := "apple\ncherry\nbanana\n"; string(got) != want {
+		t.Errorf("output.txt = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,423 @@
+package sorter
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// forEachLine calls fn with each line read from r, using bufio.Reader.
+// ReadLine like the rest of the package instead of bufio.Scanner, so lines
+// longer than bufio.MaxScanTokenSize (64KB) - which the segmented-output
+// feature can legitimately produce - don't fail the read. Iteration stops
+// at the first error fn returns.
+func forEachLine(r io.Reader, fn func(line string) error) error {
+	const op = "forEachLine"
+
+	reader := bufio.NewReader(r)
+
+	var buf []byte
+	for {
+		line, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// ReadLine hands back a line longer than its buffer in pieces;
+		// join them so fn always sees the whole line.
+		buf = append(buf, line...)
+		if isPrefix {
+			continue
+		}
+
+		if err := fn(string(buf)); err != nil {
+			return err
+		}
+		buf = buf[:0]
+	}
+}
+
+// readManifestFile reads the JSONL manifest written by writeManifestFile. A
+// missing file returns an error satisfying errors.Is(err, os.ErrNotExist).
+func readManifestFile(path string) ([]segmentManifestEntry, error) {
+	const op = "readManifestFile"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var manifest []segmentManifestEntry
+
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var entry segmentManifestEntry
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		manifest = append(manifest, entry)
+	}
+
+	return manifest, nil
+}
+
+// baselineItem is the current head line of one bucket's (count's) segment
+// stream, tracked by which entry in baselineStream.readers it came from.
+type baselineItem struct {
+	line      string
+	count     int
+	readerIdx int
+}
+
+// recordLine strips the "\t<count>" suffix writeToFileWithCount appends to
+// every record it writes to a bucket/segment file, recovering the original
+// line so the baseline stream can be compared against and merged with the
+// delta's raw (un-suffixed) lines.
+func recordLine(raw string) string {
+	if idx := strings.LastIndexByte(raw, '\t'); idx >= 0 {
+		return raw[:idx]
+	}
+
+	return raw
+}
+
+// baselineHeap merges the per-bucket streams by line, the same way
+// minLineHeap merges sub-files during the original sort.
+type baselineHeap struct {
+	items []baselineItem
+	less  func(a, b string) bool
+}
+
+func (h *baselineHeap) Len() int { return len(h.items) }
+
+func (h *baselineHeap) Less(i, j int) bool { return h.less(h.items[i].line, h.items[j].line) }
+
+func (h *baselineHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *baselineHeap) Push(x any) {
+	h.items = append(h.items, x.(baselineItem))
+}
+
+func (h *baselineHeap) Pop() any {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	h.items = old[:n-1]
+	return item
+}
+
+// baselineStream exposes a previous run's (line, count) pairs as a single
+// stream sorted by line, merged on the fly from the per-bucket segment
+// files recorded in the "output.index" manifest. Each bucket's segments are
+// already in ascending line order - that's the order mergeAndCount wrote
+// them in - so this is a k-way merge across buckets, not a full read of the
+// baseline into memory: one open *bufio.Reader and one pending line per
+// bucket, however many lines the bucket holds on disk.
+type baselineStream struct {
+	h       *baselineHeap
+	readers []*bufio.Reader
+	files   []*os.File
+}
+
+// openBaselineStream opens the manifest next to prevOutput and seeds the
+// merge heap with the first line of every bucket. It returns an error
+// satisfying errors.Is(err, os.ErrNotExist) (wrapped) when no manifest is
+// found, since a plain CreateSortedCountFile/CreateTopKFile output doesn't
+// retain per-line counts and can't be streamed as a sorted-by-line baseline
+// without re-deriving them - see Update's doc comment.
+func openBaselineStream(prevOutput string, less func(a, b string) bool) (*baselineStream, error) {
+	const op = "openBaselineStream"
+
+	manifestPath := filepath.Join(filepath.Dir(prevOutput), manifestFileName)
+
+	manifest, err := readManifestFile(manifestPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: no %q manifest next to %q (produce prevOutput with CreateSegmentedOutput): %w", op, manifestFileName, prevOutput, err)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Group segment paths by count, preserving the order they were written
+	// in (ascending within a bucket, since that's the order a bucket's
+	// segments were created).
+	var counts []int
+	segmentsByCount := make(map[int][]string)
+	for _, entry := range manifest {
+		if _, seen := segmentsByCount[entry.Count]; !seen {
+			counts = append(counts, entry.Count)
+		}
+
+		segmentsByCount[entry.Count] = append(segmentsByCount[entry.Count], entry.SegmentPath)
+	}
+
+	bs := &baselineStream{h: &baselineHeap{less: less}}
+
+	for _, count := range counts {
+		group := make([]io.Reader, 0, len(segmentsByCount[count]))
+
+		for _, path := range segmentsByCount[count] {
+			f, err := os.Open(path)
+			if err != nil {
+				bs.Close()
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+
+			bs.files = append(bs.files, f)
+			group = append(group, f)
+		}
+
+		reader := bufio.NewReader(io.MultiReader(group...))
+		readerIdx := len(bs.readers)
+		bs.readers = append(bs.readers, reader)
+
+		line, _, err := reader.ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				continue
+			}
+
+			bs.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		bs.h.items = append(bs.h.items, baselineItem{line: recordLine(string(line)), count: count, readerIdx: readerIdx})
+	}
+
+	heap.Init(bs.h)
+
+	return bs, nil
+}
+
+// Peek returns the smallest remaining (line, count) pair without consuming
+// it. ok is false once the baseline is exhausted.
+func (bs *baselineStream) Peek() (line string, count int, ok bool) {
+	if bs.h.Len() == 0 {
+		return "", 0, false
+	}
+
+	top := bs.h.items[0]
+
+	return top.line, top.count, true
+}
+
+// Advance consumes the current Peek result and pulls the next line from
+// whichever bucket it came from.
+func (bs *baselineStream) Advance() error {
+	const op = "baselineStream.Advance"
+
+	if bs.h.Len() == 0 {
+		return nil
+	}
+
+	item := heap.Pop(bs.h).(baselineItem)
+
+	line, _, err := bs.readers[item.readerIdx].ReadLine()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	heap.Push(bs.h, baselineItem{line: recordLine(string(line)), count: item.count, readerIdx: item.readerIdx})
+
+	return nil
+}
+
+// Close closes every segment file opened for this stream.
+func (bs *baselineStream) Close() error {
+	var firstErr error
+
+	for _, f := range bs.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// parseDelta reads "+line"/"-line" rows from delta and tallies how many
+// times each line was added or removed.
+func parseDelta(delta io.Reader) (adds, removes map[string]int, err error) {
+	const op = "parseDelta"
+
+	adds = make(map[string]int)
+	removes = make(map[string]int)
+
+	err = forEachLine(delta, func(row string) error {
+		if row == "" {
+			return nil
+		}
+
+		switch row[0] {
+		case '+':
+			adds[row[1:]]++
+		case '-':
+			removes[row[1:]]++
+		default:
+			return fmt.Errorf("%s: delta row %q missing +/- prefix", op, row)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return adds, removes, nil
+}
+
+// sortedKeys returns m's keys ordered by the Sorter's comparator, so the
+// delta's adds/removes can be walked in lockstep with the baseline stream.
+func (s *Sorter) sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return s.lineLess(keys[i], keys[j]) })
+
+	return keys
+}
+
+// mergeBaselineWithDelta walks the baseline stream and the sorted add/remove
+// line lists in a single pass (a three-way streaming merge), and writes each
+// surviving line's adjusted count into the same per-count bucket files
+// CreateSortedCountFile uses via writeToFileWithCount - so the final
+// descending walk (writeBucketsDescending) streams from disk instead of
+// holding the merged result in memory.
+func (s *Sorter) mergeBaselineWithDelta(baseline *baselineStream, addLines []string, adds map[string]int, removeLines []string, removes map[string]int) error {
+	const op = "Sorter.mergeBaselineWithDelta"
+
+	ai, ri := 0, 0
+
+	for {
+		blLine, blCount, hasBaseline := baseline.Peek()
+		hasAdd := ai < len(addLines)
+
+		if !hasBaseline && !hasAdd {
+			break
+		}
+
+		var nextLine string
+		switch {
+		case hasBaseline && hasAdd:
+			if s.lineLess(addLines[ai], blLine) {
+				nextLine = addLines[ai]
+			} else {
+				nextLine = blLine
+			}
+		case hasBaseline:
+			nextLine = blLine
+		default:
+			nextLine = addLines[ai]
+		}
+
+		count := 0
+
+		if hasBaseline && blLine == nextLine {
+			count = blCount
+
+			if err := baseline.Advance(); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if hasAdd && addLines[ai] == nextLine {
+			count += adds[nextLine]
+			ai++
+		}
+
+		// Advance the remove cursor up to nextLine and apply it if it
+		// matches; removals for lines absent from both streams are simply
+		// never reached, which is the correct no-op.
+		for ri < len(removeLines) && s.lineLess(removeLines[ri], nextLine) {
+			ri++
+		}
+
+		if ri < len(removeLines) && removeLines[ri] == nextLine {
+			count -= removes[nextLine]
+			ri++
+		}
+
+		// A removal dropping a line's last occurrence (count <= 0) simply
+		// isn't written to any bucket, which drops it from the output.
+		if count > 0 {
+			if err := s.writeToFileWithCount(nextLine, count); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Update performs an incremental re-sort: it streams prevOutput's
+// "output.index" manifest (written by CreateSegmentedOutput) as an
+// already-sorted, already-counted baseline, merges in a delta stream of
+// "+line"/"-line" records in a single pass, and writes the result to
+// "output.txt". After calling, the caller should still defer Close.
+//
+// prevOutput must have been produced by CreateSegmentedOutput, at the same
+// chunkSize and comparator as this Sorter, or the recovered baseline counts
+// and ordering won't mean what this run thinks they mean. A plain
+// CreateSortedCountFile/CreateTopKFile output has no manifest and doesn't
+// retain per-line counts, so it is rejected rather than silently treated as
+// "every line occurred once". A removal that drops a line's count to zero
+// removes it from the output entirely; an add for a line absent from the
+// baseline promotes it straight into whatever count bucket its delta tally
+// lands in.
+//
+// The delta itself is tallied in two in-memory maps, which suits the
+// "slowly-changing inputs" workloads this feature targets; it does not
+// (yet) fall back to sorting an oversized delta into its own chunk files.
+func (s *Sorter) Update(prevOutput string, delta io.Reader) (os.FileInfo, error) {
+	const op = "Sorter.Update"
+
+	baseline, err := openBaselineStream(prevOutput, s.lineLess)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer baseline.Close()
+
+	adds, removes, err := parseDelta(delta)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	addLines := s.sortedKeys(adds)
+	removeLines := s.sortedKeys(removes)
+
+	if err := s.mergeBaselineWithDelta(baseline, addLines, adds, removeLines, removes); err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	outputFile, err := os.Create("output.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+	defer outputFile.Close()
+
+	if err := s.writeBucketsDescending(outputFile); err != nil {
+		return nil, fmt.Errorf("%s, %w", op, err)
+	}
+
+	return outputFile.Stat()
+}